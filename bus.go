@@ -0,0 +1,160 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Bus - in-memory publish/subscribe event bus used to wire up local handlers
+// as well as the handlers synthesized by the RPC client/server pair
+type Bus struct {
+	handlers map[string][]*eventHandler
+	lock     sync.Mutex
+}
+
+type eventHandler struct {
+	callback reflect.Value
+	once     bool
+}
+
+// NewBus - creates a new, empty Bus
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]*eventHandler)}
+}
+
+func (bus *Bus) subscribe(topic string, fn interface{}, once bool) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Errorf("%s is not of type reflect.Func", v.Kind())
+	}
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	bus.handlers[topic] = append(bus.handlers[topic], &eventHandler{v, once})
+	subscriptionsGauge.WithLabelValues(topic).Inc()
+	return nil
+}
+
+// Subscribe - subscribes fn to be called every time topic is published
+func (bus *Bus) Subscribe(topic string, fn interface{}) error {
+	return bus.subscribe(topic, fn, false)
+}
+
+// SubscribeOnce - subscribes fn to be called on the next publish to topic, then removed
+func (bus *Bus) SubscribeOnce(topic string, fn interface{}) error {
+	return bus.subscribe(topic, fn, true)
+}
+
+// SubscriptionHandle - lets a caller unsubscribe a handler it doesn't hold a
+// reference to anymore, e.g. a closure or a handler synthesized on its behalf
+type SubscriptionHandle struct {
+	bus   *Bus
+	topic string
+	fn    interface{}
+}
+
+// Unsubscribe - removes the handler this handle was issued for
+func (h SubscriptionHandle) Unsubscribe() error {
+	return h.bus.Unsubscribe(h.topic, h.fn)
+}
+
+// SubscribeWithHandle - subscribes fn to topic and returns a handle that can
+// unsubscribe it later without the caller having to keep fn around
+func (bus *Bus) SubscribeWithHandle(topic string, fn interface{}) (SubscriptionHandle, error) {
+	if err := bus.subscribe(topic, fn, false); err != nil {
+		return SubscriptionHandle{}, err
+	}
+	return SubscriptionHandle{bus: bus, topic: topic, fn: fn}, nil
+}
+
+// SubscribeContext - subscribes fn to topic and automatically unsubscribes it
+// once ctx is cancelled
+func (bus *Bus) SubscribeContext(ctx context.Context, topic string, fn interface{}) error {
+	handle, err := bus.SubscribeWithHandle(topic, fn)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		handle.Unsubscribe()
+	}()
+	return nil
+}
+
+// Unsubscribe - removes fn from topic's list of subscribers
+func (bus *Bus) Unsubscribe(topic string, fn interface{}) error {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	handlers, ok := bus.handlers[topic]
+	if !ok || len(handlers) == 0 {
+		return fmt.Errorf("topic %s doesn't exist", topic)
+	}
+	ptr := reflect.ValueOf(fn).Pointer()
+	remaining := handlers[:0]
+	removed := 0
+	for _, h := range handlers {
+		if h.callback.Pointer() == ptr {
+			removed++
+			continue
+		}
+		remaining = append(remaining, h)
+	}
+	bus.handlers[topic] = remaining
+	if removed > 0 {
+		subscriptionsGauge.WithLabelValues(topic).Sub(float64(removed))
+	}
+	return nil
+}
+
+// HasCallback - reports whether topic currently has at least one subscriber
+func (bus *Bus) HasCallback(topic string) bool {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	return len(bus.handlers[topic]) > 0
+}
+
+// Publish - calls every handler subscribed to topic with the given arguments
+func (bus *Bus) Publish(topic string, args ...interface{}) {
+	publishedEventsTotal.WithLabelValues(topic).Inc()
+
+	bus.lock.Lock()
+	handlers, ok := bus.handlers[topic]
+	if !ok {
+		bus.lock.Unlock()
+		return
+	}
+	toCall := make([]*eventHandler, len(handlers))
+	copy(toCall, handlers)
+	remaining := handlers[:0]
+	removedOnce := 0
+	for _, h := range handlers {
+		if h.once {
+			removedOnce++
+			continue
+		}
+		remaining = append(remaining, h)
+	}
+	bus.handlers[topic] = remaining
+	bus.lock.Unlock()
+
+	if removedOnce > 0 {
+		subscriptionsGauge.WithLabelValues(topic).Sub(float64(removedOnce))
+	}
+
+	for _, h := range toCall {
+		callHandler(h.callback, args)
+	}
+}
+
+func callHandler(callback reflect.Value, args []interface{}) {
+	passedArgs := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			passedArgs[i] = reflect.New(callback.Type().In(i)).Elem()
+		} else {
+			passedArgs[i] = reflect.ValueOf(arg)
+		}
+	}
+	callback.Call(passedArgs)
+}
@@ -0,0 +1,68 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribeWithHandleUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	calls := 0
+	handle, err := bus.SubscribeWithHandle("topic", func() { calls++ })
+	if err != nil {
+		t.Fatalf("SubscribeWithHandle: %v", err)
+	}
+
+	bus.Publish("topic")
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+
+	if err := handle.Unsubscribe(); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	bus.Publish("topic")
+	if calls != 1 {
+		t.Fatalf("calls = %d after unsubscribe, want 1", calls)
+	}
+	if bus.HasCallback("topic") {
+		t.Error("topic should have no subscribers left")
+	}
+}
+
+func TestSubscribeContextCancel(t *testing.T) {
+	bus := NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	if err := bus.SubscribeContext(ctx, "topic", func() { calls++ }); err != nil {
+		t.Fatalf("SubscribeContext: %v", err)
+	}
+
+	bus.Publish("topic")
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+
+	cancel()
+	waitUntil(t, func() bool { return !bus.HasCallback("topic") })
+
+	bus.Publish("topic")
+	if calls != 1 {
+		t.Fatalf("calls = %d after context cancel, want 1", calls)
+	}
+}
+
+// waitUntil polls cond until it's true or fails the test after a timeout,
+// since SubscribeContext's teardown happens on its own goroutine
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
@@ -6,7 +6,13 @@ import (
 	"net"
 	"net/http"
 	"net/rpc"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -14,10 +20,31 @@ const (
 	PublishService = "ClientService.PushEvent"
 )
 
-// ClientArg - object containing event for client to publish locally
+// ClientOptions - tuning knobs for how hard the client retries connecting to a
+// server before giving up
+type ClientOptions struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Codec          Codec
+}
+
+// DefaultClientOptions - sane defaults used when NewClient is called with nil options
+func DefaultClientOptions() *ClientOptions {
+	return &ClientOptions{
+		MaxRetries:     5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Codec:          GobCodec{},
+	}
+}
+
+// ClientArg - object carrying an event for the client to publish locally. Args
+// are encoded individually with the codec named by Codec, negotiated at Register time
 type ClientArg struct {
-	Args  []interface{}
+	Codec string
 	Topic string
+	Args  [][]byte
 }
 
 // Client - object capable of subscribing to a remote event bus
@@ -28,40 +55,73 @@ type Client struct {
 	service  *ClientService
 }
 
-// NewClient - create a client object with the address and server path
-func NewClient(address, path string, eventBus *Bus) *Client {
+// NewClient - create a client object with the address and server path.
+// opts may be nil, in which case DefaultClientOptions are used
+func NewClient(address, path string, eventBus *Bus, opts *ClientOptions) *Client {
+	if opts == nil {
+		opts = DefaultClientOptions()
+	}
 	client := new(Client)
 	client.eventBus = eventBus
 	client.address = address
 	client.path = path
-	client.service = &ClientService{client, &sync.WaitGroup{}, false}
+	client.service = &ClientService{
+		client:         client,
+		wg:             &sync.WaitGroup{},
+		subscriptions:  make(map[string]SubscriptionHandle),
+		opts:           opts,
+		conns:          make(map[string]*rpc.Client),
+		lastRPCSuccess: make(map[string]time.Time),
+	}
 	return client
 }
 
 func (client *Client) doSubscribe(topic string, fn interface{}, serverAddr, serverPath string, subscribeType SubscribeType) error {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("Server not found -", r)
-		}
-	}()
-
-	rpcClient, err := rpc.DialHTTPPath("tcp", serverAddr, serverPath)
-	defer rpcClient.Close()
+	rpcClient, err := client.service.dial(serverAddr, serverPath)
 	if err != nil {
-		return fmt.Errorf("dialing: %v", err)
+		return err
+	}
+	args := &SubscribeArg{
+		ClientAddr:    client.address,
+		ClientPath:    client.path,
+		ServiceMethod: PublishService,
+		Type:          subscribeType,
+		Topic:         topic,
+		Codec:         client.service.opts.Codec.ContentType(),
 	}
-	args := &SubscribeArg{client.address, client.path, PublishService, subscribeType, topic}
 	reply := new(bool)
-	err = rpcClient.Call(RegisterService, args, reply)
-	if err != nil {
+	if err := rpcClient.Call(RegisterService, args, reply); err != nil {
+		client.service.dropConn(serverAddr, serverPath)
 		return fmt.Errorf("Register error: %v", err)
 	}
-	if *reply {
-		client.eventBus.Subscribe(topic, fn)
+	client.service.recordSuccess(serverAddr, serverPath)
+	if !*reply {
+		return nil
 	}
+
+	var handle SubscriptionHandle
+	if subscribeType == SubscribeTypeOnce {
+		if err := client.eventBus.SubscribeOnce(topic, fn); err != nil {
+			return err
+		}
+		handle = SubscriptionHandle{bus: client.eventBus, topic: topic, fn: fn}
+	} else {
+		handle, err = client.eventBus.SubscribeWithHandle(topic, fn)
+		if err != nil {
+			return err
+		}
+	}
+	client.service.trackSubscription(subscriptionKey(serverAddr, serverPath, topic), handle)
 	return nil
 }
 
+// subscriptionKey identifies a remote subscription by the server it was
+// registered with as well as its topic, so the same topic held with two
+// different servers doesn't clobber one or the other
+func subscriptionKey(serverAddr, serverPath, topic string) string {
+	return serverAddr + "|" + serverPath + "|" + topic
+}
+
 //Subscribe subscribes to a topic in a remote event bus
 func (client *Client) Subscribe(topic string, fn interface{}, serverAddr, serverPath string) error {
 	return client.doSubscribe(topic, fn, serverAddr, serverPath, SubscribeTypePermanent)
@@ -72,45 +132,298 @@ func (client *Client) SubscribeOnce(topic string, fn interface{}, serverAddr, se
 	return client.doSubscribe(topic, fn, serverAddr, serverPath, SubscribeTypeOnce)
 }
 
+// Unsubscribe tears down a single remote subscription held with a server, and
+// removes the matching handler from the client's own Bus
+func (client *Client) Unsubscribe(topic string, serverAddr, serverPath string) error {
+	rpcClient, err := client.service.dial(serverAddr, serverPath)
+	if err != nil {
+		return err
+	}
+
+	args := &SubscribeArg{
+		ClientAddr:    client.address,
+		ClientPath:    client.path,
+		ServiceMethod: PublishService,
+		Type:          SubscribeTypePermanent,
+		Topic:         topic,
+		Codec:         client.service.opts.Codec.ContentType(),
+	}
+	reply := new(bool)
+	if err := rpcClient.Call(UnregisterService, args, reply); err != nil {
+		client.service.dropConn(serverAddr, serverPath)
+		return fmt.Errorf("Unregister error: %v", err)
+	}
+	client.service.recordSuccess(serverAddr, serverPath)
+	client.service.untrackSubscription(subscriptionKey(serverAddr, serverPath, topic))
+	return nil
+}
+
+// UnsubscribeAll tears down every remote subscription held with a server, and
+// removes all of the client's matching handlers from its own Bus
+func (client *Client) UnsubscribeAll(serverAddr, serverPath string) error {
+	rpcClient, err := client.service.dial(serverAddr, serverPath)
+	if err != nil {
+		return err
+	}
+
+	args := &SubscribeArg{
+		ClientAddr:    client.address,
+		ClientPath:    client.path,
+		ServiceMethod: PublishService,
+		Type:          SubscribeTypePermanent,
+		Topic:         "",
+		Codec:         client.service.opts.Codec.ContentType(),
+	}
+	reply := new(bool)
+	if err := rpcClient.Call(UnregisterAllService, args, reply); err != nil {
+		client.service.dropConn(serverAddr, serverPath)
+		return fmt.Errorf("UnregisterAll error: %v", err)
+	}
+	client.service.recordSuccess(serverAddr, serverPath)
+	client.service.untrackSubscriptionsForServer(serverAddr, serverPath)
+	return nil
+}
+
 // Start - starts the client service to listen to remote events
 func (client *Client) Start() error {
 	service := client.service
-	if !service.started {
-		server := rpc.NewServer()
-		server.Register(service)
-		server.HandleHTTP(client.path, "/debug"+client.path)
-		l, err := net.Listen("tcp", client.address)
-		if err != nil {
-			return fmt.Errorf("listen error: %v", err)
-		}
-		service.wg.Add(1)
-		service.started = true
-		go http.Serve(l, nil)
-	} else {
+	if !atomic.CompareAndSwapInt32(&service.started, 0, 1) {
 		return errors.New("Client service already started")
 	}
+	rpcServer := rpc.NewServer()
+	rpcServer.Register(service)
+
+	mux := http.NewServeMux()
+	mux.Handle(client.path, rpcServer)
+	mux.HandleFunc("/healthz", service.healthzHandler)
+	mux.HandleFunc("/readyz", service.readyzHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	l, err := net.Listen("tcp", client.address)
+	if err != nil {
+		atomic.StoreInt32(&service.started, 0)
+		return fmt.Errorf("listen error: %v", err)
+	}
+	service.wg.Add(1)
+	go http.Serve(l, mux)
 	return nil
 }
 
-// Stop - signal for the service to stop serving
+// Stop - signal for the service to stop serving, tearing down every
+// subscription it locally holds
 func (client *Client) Stop() {
 	service := client.service
-	if service.started {
+	if atomic.CompareAndSwapInt32(&service.started, 1, 0) {
 		service.wg.Done()
-		service.started = false
+		service.untrackAllSubscriptions()
 	}
 }
 
 // ClientService - service object listening to events published in a remote event bus
 type ClientService struct {
-	client  *Client
-	wg      *sync.WaitGroup
-	started bool
+	client         *Client
+	wg             *sync.WaitGroup
+	started        int32 // accessed atomically; 0 = stopped, 1 = started
+	subscriptions  map[string]SubscriptionHandle
+	lock           sync.Mutex
+	opts           *ClientOptions
+	conns          map[string]*rpc.Client
+	connsLock      sync.Mutex
+	lastRPCSuccess map[string]time.Time
+}
+
+func (service *ClientService) isStarted() bool {
+	return atomic.LoadInt32(&service.started) == 1
+}
+
+func (service *ClientService) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthStatus(w, service.isStarted() && busResponsive(service.client.eventBus), "not healthy")
+}
+
+// readyzHandler additionally fails if any server the client currently holds a
+// connection to has never completed a successful RPC round trip
+func (service *ClientService) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !service.isStarted() || !busResponsive(service.client.eventBus) {
+		writeHealthStatus(w, false, "not healthy")
+		return
+	}
+	service.connsLock.Lock()
+	defer service.connsLock.Unlock()
+	for key := range service.conns {
+		if _, ok := service.lastRPCSuccess[key]; !ok {
+			writeHealthStatus(w, false, fmt.Sprintf("server %s never reachable", key))
+			return
+		}
+	}
+	writeHealthStatus(w, true, "")
+}
+
+func (service *ClientService) recordSuccess(serverAddr, serverPath string) {
+	key := serverAddr + "|" + serverPath
+	service.connsLock.Lock()
+	defer service.connsLock.Unlock()
+	service.lastRPCSuccess[key] = time.Now()
+}
+
+// dial returns a cached RPC connection to the server, reconnecting with
+// exponential backoff if none is cached yet. The backoff retries happen
+// outside connsLock, so a server that's down doesn't block dial, dropConn or
+// recordSuccess for every other server the client talks to
+func (service *ClientService) dial(serverAddr, serverPath string) (*rpc.Client, error) {
+	key := serverAddr + "|" + serverPath
+
+	service.connsLock.Lock()
+	conn, ok := service.conns[key]
+	service.connsLock.Unlock()
+	if ok {
+		return conn, nil
+	}
+
+	conn, err := service.reconnect(serverAddr, serverPath)
+	if err != nil {
+		return nil, err
+	}
+
+	service.connsLock.Lock()
+	defer service.connsLock.Unlock()
+	if existing, ok := service.conns[key]; ok {
+		conn.Close()
+		return existing, nil
+	}
+	service.conns[key] = conn
+	return conn, nil
+}
+
+func (service *ClientService) reconnect(serverAddr, serverPath string) (*rpc.Client, error) {
+	backoff := service.opts.InitialBackoff
+	var lastErr error
+	for attempt := 0; attempt <= service.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > service.opts.MaxBackoff {
+				backoff = service.opts.MaxBackoff
+			}
+		}
+		conn, err := rpc.DialHTTPPath("tcp", serverAddr, serverPath)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("dialing: %v", lastErr)
+}
+
+// dropConn evicts a cached connection so the next dial reconnects from scratch
+func (service *ClientService) dropConn(serverAddr, serverPath string) {
+	key := serverAddr + "|" + serverPath
+
+	service.connsLock.Lock()
+	defer service.connsLock.Unlock()
+	if conn, ok := service.conns[key]; ok {
+		conn.Close()
+		delete(service.conns, key)
+	}
 }
 
 // PushEvent - exported service to listening to remote events
 func (service *ClientService) PushEvent(arg *ClientArg, reply *bool) error {
-	service.client.eventBus.Publish(arg.Topic, arg.Args...)
+	codec, ok := CodecByName(arg.Codec)
+	if !ok {
+		codec = GobCodec{}
+	}
+	fnType, hasHandler := service.handlerTypeForTopic(arg.Topic)
+	args := make([]interface{}, len(arg.Args))
+	for i, payload := range arg.Args {
+		if !hasHandler {
+			// no locally subscribed handler to size the argument against, so
+			// fall back to decoding into an untyped value. Only JSONCodec can
+			// do that; GobCodec and ProtobufCodec return an error here
+			var v interface{}
+			if err := codec.Unmarshal(payload, &v); err != nil {
+				return fmt.Errorf("decoding arg %d: %v", i, err)
+			}
+			args[i] = v
+			continue
+		}
+		paramType, err := argTypeAt(fnType, i)
+		if err != nil {
+			return err
+		}
+		v, err := decodeArg(codec, payload, paramType)
+		if err != nil {
+			return fmt.Errorf("decoding arg %d: %v", i, err)
+		}
+		args[i] = v
+	}
+	service.client.eventBus.Publish(arg.Topic, args...)
 	*reply = true
 	return nil
 }
+
+// handlerTypeForTopic returns the reflect.Type of a handler currently
+// subscribed for topic, so PushEvent can decode each argument into the
+// concrete type the handler actually expects instead of an untyped value.
+// Codecs like ProtobufCodec can't decode into interface{} at all.
+//
+// This matches by topic alone, not by which server the subscription came
+// from: ClientArg carries no originating-server identity for PushEvent to
+// disambiguate against, so a client that subscribes to the same topic via
+// two servers must use the same handler signature for both, or PushEvent may
+// decode against the wrong one.
+func (service *ClientService) handlerTypeForTopic(topic string) (reflect.Type, bool) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	for _, handle := range service.subscriptions {
+		if handle.topic == topic {
+			return reflect.TypeOf(handle.fn), true
+		}
+	}
+	return nil, false
+}
+
+func (service *ClientService) trackSubscription(key string, handle SubscriptionHandle) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	service.subscriptions[key] = handle
+}
+
+func (service *ClientService) untrackSubscription(key string) {
+	service.lock.Lock()
+	handle, ok := service.subscriptions[key]
+	if ok {
+		delete(service.subscriptions, key)
+	}
+	service.lock.Unlock()
+	if ok {
+		handle.Unsubscribe()
+	}
+}
+
+// untrackSubscriptionsForServer drops every subscription held with the given
+// server, leaving subscriptions held with other servers untouched
+func (service *ClientService) untrackSubscriptionsForServer(serverAddr, serverPath string) {
+	prefix := serverAddr + "|" + serverPath + "|"
+	service.lock.Lock()
+	var handles []SubscriptionHandle
+	for key, handle := range service.subscriptions {
+		if strings.HasPrefix(key, prefix) {
+			handles = append(handles, handle)
+			delete(service.subscriptions, key)
+		}
+	}
+	service.lock.Unlock()
+	for _, handle := range handles {
+		handle.Unsubscribe()
+	}
+}
+
+func (service *ClientService) untrackAllSubscriptions() {
+	service.lock.Lock()
+	subscriptions := service.subscriptions
+	service.subscriptions = make(map[string]SubscriptionHandle)
+	service.lock.Unlock()
+	for _, handle := range subscriptions {
+		handle.Unsubscribe()
+	}
+}
@@ -0,0 +1,126 @@
+package eventbus
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Codec - pluggable encoding for the positional arguments exchanged between
+// Client and Server, so publishers aren't stuck with net/rpc's gob encoding
+type Codec interface {
+	// ContentType - short identifier negotiated in SubscribeArg.Codec
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var codecs = map[string]Codec{
+	"gob":      GobCodec{},
+	"json":     JSONCodec{},
+	"protobuf": ProtobufCodec{},
+}
+
+// CodecByName - looks up a registered Codec by its ContentType identifier
+func CodecByName(name string) (Codec, bool) {
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+// GobCodec - default codec backed by encoding/gob, kept for backwards compatibility
+// with publishers that already gob.Register their concrete argument types
+type GobCodec struct{}
+
+// ContentType - see Codec
+func (GobCodec) ContentType() string { return "gob" }
+
+// Marshal - see Codec
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal - see Codec
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec - codec backed by encoding/json, usable by non-Go subscribers
+type JSONCodec struct{}
+
+// ContentType - see Codec
+func (JSONCodec) ContentType() string { return "json" }
+
+// Marshal - see Codec
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal - see Codec
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtobufCodec - codec backed by protocol buffers; marshaled values must
+// implement proto.Message
+type ProtobufCodec struct{}
+
+// ContentType - see Codec
+func (ProtobufCodec) ContentType() string { return "protobuf" }
+
+// Marshal - see Codec
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec: %T does not implement proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal - see Codec
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf codec: %T does not implement proto.Message", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// argTypeAt resolves the reflect.Type fnType expects for its i'th positional
+// argument, accounting for a variadic trailing parameter
+func argTypeAt(fnType reflect.Type, i int) (reflect.Type, error) {
+	switch {
+	case fnType.IsVariadic() && i >= fnType.NumIn()-1:
+		return fnType.In(fnType.NumIn() - 1).Elem(), nil
+	case i < fnType.NumIn():
+		return fnType.In(i), nil
+	default:
+		return nil, fmt.Errorf("event carries more arguments than %s accepts", fnType)
+	}
+}
+
+// decodeArg unmarshals payload with codec into the type paramType describes.
+// Types that are already pointers (as proto.Message implementations must be)
+// are decoded in place rather than as a pointer-to-pointer, since codecs like
+// ProtobufCodec type-assert v directly against the target interface
+func decodeArg(codec Codec, payload []byte, paramType reflect.Type) (interface{}, error) {
+	if paramType.Kind() == reflect.Ptr {
+		v := reflect.New(paramType.Elem())
+		if err := codec.Unmarshal(payload, v.Interface()); err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	}
+	v := reflect.New(paramType)
+	if err := codec.Unmarshal(payload, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
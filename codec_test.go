@@ -0,0 +1,103 @@
+package eventbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodecByName(t *testing.T) {
+	for _, name := range []string{"gob", "json", "protobuf"} {
+		if _, ok := CodecByName(name); !ok {
+			t.Errorf("CodecByName(%q) not found", name)
+		}
+	}
+	if _, ok := CodecByName("xml"); ok {
+		t.Error("CodecByName(\"xml\") should not be registered")
+	}
+}
+
+func TestGobCodecRoundTrip(t *testing.T) {
+	codec := GobCodec{}
+	data, err := codec.Marshal("hello")
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var got string
+	if err := codec.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got != "hello" {
+		t.Errorf("got %q, want %q", got, "hello")
+	}
+}
+
+func TestJSONCodecRoundTrip(t *testing.T) {
+	codec := JSONCodec{}
+	type payload struct {
+		Name string
+		Age  int
+	}
+	in := payload{Name: "ada", Age: 36}
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var out payload
+	if err := codec.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if out != in {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestArgTypeAt(t *testing.T) {
+	fn := func(a string, b ...int) {}
+	typ := reflect.TypeOf(fn)
+
+	if got, err := argTypeAt(typ, 0); err != nil || got.Kind() != reflect.String {
+		t.Errorf("arg 0: got %v, err %v", got, err)
+	}
+	if got, err := argTypeAt(typ, 1); err != nil || got.Kind() != reflect.Int {
+		t.Errorf("arg 1 (variadic): got %v, err %v", got, err)
+	}
+	if got, err := argTypeAt(typ, 5); err != nil || got.Kind() != reflect.Int {
+		t.Errorf("arg 5 (variadic): got %v, err %v", got, err)
+	}
+}
+
+func TestArgTypeAtOutOfRange(t *testing.T) {
+	fn := func(a string) {}
+	typ := reflect.TypeOf(fn)
+	if _, err := argTypeAt(typ, 1); err == nil {
+		t.Error("expected error for an argument beyond what a non-variadic fn accepts")
+	}
+}
+
+func TestDecodeArgPointerType(t *testing.T) {
+	type payload struct{ Name string }
+	fn := func(p *payload) {}
+	typ := reflect.TypeOf(fn)
+
+	paramType, err := argTypeAt(typ, 0)
+	if err != nil {
+		t.Fatalf("argTypeAt: %v", err)
+	}
+
+	data, err := JSONCodec{}.Marshal(&payload{Name: "ada"})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	v, err := decodeArg(JSONCodec{}, data, paramType)
+	if err != nil {
+		t.Fatalf("decodeArg: %v", err)
+	}
+	got, ok := v.(*payload)
+	if !ok {
+		t.Fatalf("decodeArg returned %T, want *payload", v)
+	}
+	if got.Name != "ada" {
+		t.Errorf("got %+v, want Name=ada", got)
+	}
+}
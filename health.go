@@ -0,0 +1,38 @@
+package eventbus
+
+import (
+	"net/http"
+	"time"
+)
+
+// healthcheckTopic - internal topic used to verify a Bus is still pumping events
+const healthcheckTopic = "eventbus.internal.healthcheck"
+
+// healthcheckTimeout - how long a healthz/readyz probe waits for the self-publish round trip
+const healthcheckTimeout = time.Second
+
+// busResponsive self-publishes on an internal topic and waits for the round
+// trip to come back, to verify the Bus is still dispatching events
+func busResponsive(bus *Bus) bool {
+	done := make(chan struct{}, 1)
+	var handler interface{} = func() { done <- struct{}{} }
+	if err := bus.SubscribeOnce(healthcheckTopic, handler); err != nil {
+		return false
+	}
+	bus.Publish(healthcheckTopic)
+	select {
+	case <-done:
+		return true
+	case <-time.After(healthcheckTimeout):
+		bus.Unsubscribe(healthcheckTopic, handler)
+		return false
+	}
+}
+
+func writeHealthStatus(w http.ResponseWriter, ok bool, reason string) {
+	if ok {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Error(w, reason, http.StatusServiceUnavailable)
+}
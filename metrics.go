@@ -0,0 +1,29 @@
+package eventbus
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	publishedEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eventbus_published_events_total",
+		Help: "Total number of events published on a Bus, by topic.",
+	}, []string{"topic"})
+
+	subscriptionsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "eventbus_subscriptions",
+		Help: "Current number of subscribers held per topic.",
+	}, []string{"topic"})
+
+	pushEventLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "eventbus_push_event_latency_seconds",
+		Help: "Latency of a successful PushEvent round trip from Server to a remote Client, by topic.",
+	}, []string{"topic"})
+
+	droppedSubscribersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "eventbus_dropped_subscribers_total",
+		Help: "Total number of remote subscribers evicted after exhausting their push retries, by topic.",
+	}, []string{"topic"})
+)
+
+func init() {
+	prometheus.MustRegister(publishedEventsTotal, subscriptionsGauge, pushEventLatency, droppedSubscribersTotal)
+}
@@ -0,0 +1,354 @@
+package eventbus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// RegisterService - Server service method used by clients to subscribe to a topic
+	RegisterService = "ServerService.Register"
+	// UnregisterService - Server service method used by clients to drop a single subscription
+	UnregisterService = "ServerService.Unregister"
+	// UnregisterAllService - Server service method used by clients to drop every subscription they hold
+	UnregisterAllService = "ServerService.UnregisterAll"
+)
+
+// SubscribeType - distinguishes a permanent remote subscription from a one-shot one
+type SubscribeType int
+
+const (
+	// SubscribeTypePermanent - the remote subscriber wants every future event
+	SubscribeTypePermanent SubscribeType = iota
+	// SubscribeTypeOnce - the remote subscriber wants only the next event
+	SubscribeTypeOnce
+)
+
+// SubscribeArg - object carrying the remote subscription details sent to Register/Unregister.
+// Codec is the ContentType of the Codec the subscriber wants its events encoded with
+type SubscribeArg struct {
+	ClientAddr    string
+	ClientPath    string
+	ServiceMethod string
+	Type          SubscribeType
+	Topic         string
+	Codec         string
+}
+
+// DroppedSubscriberTopic - internal topic published on the server's own Bus
+// whenever a remote subscriber is evicted after exhausting its push retries
+const DroppedSubscriberTopic = "eventbus.subscriber.dropped"
+
+// ServerOptions - tuning knobs for how hard the server retries a failed PushEvent
+// before giving up on a remote subscriber and evicting it
+type ServerOptions struct {
+	PushMaxRetries     int
+	PushInitialBackoff time.Duration
+	PushMaxBackoff     time.Duration
+}
+
+// DefaultServerOptions - sane defaults used when NewServer is called with nil options
+func DefaultServerOptions() *ServerOptions {
+	return &ServerOptions{
+		PushMaxRetries:     3,
+		PushInitialBackoff: 100 * time.Millisecond,
+		PushMaxBackoff:     2 * time.Second,
+	}
+}
+
+// Server - object exposing a local event bus to remote clients over RPC
+type Server struct {
+	eventBus *Bus
+	address  string
+	path     string
+	service  *ServerService
+}
+
+// NewServer - create a server object with the address and RPC path it will listen on.
+// opts may be nil, in which case DefaultServerOptions are used
+func NewServer(address, path string, eventBus *Bus, opts *ServerOptions) *Server {
+	if opts == nil {
+		opts = DefaultServerOptions()
+	}
+	server := new(Server)
+	server.eventBus = eventBus
+	server.address = address
+	server.path = path
+	server.service = &ServerService{
+		server:   server,
+		subs:     make(map[string][]*remoteSub),
+		opts:     opts,
+		lastPush: make(map[string]pushStatus),
+	}
+	return server
+}
+
+// Start - starts the server service to accept remote subscriptions
+func (server *Server) Start() error {
+	service := server.service
+	if !atomic.CompareAndSwapInt32(&service.started, 0, 1) {
+		return errors.New("Server service already started")
+	}
+	rpcServer := rpc.NewServer()
+	rpcServer.Register(service)
+
+	mux := http.NewServeMux()
+	mux.Handle(server.path, rpcServer)
+	mux.HandleFunc("/healthz", service.healthzHandler)
+	mux.HandleFunc("/readyz", service.readyzHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	l, err := net.Listen("tcp", server.address)
+	if err != nil {
+		atomic.StoreInt32(&service.started, 0)
+		return fmt.Errorf("listen error: %v", err)
+	}
+	go http.Serve(l, mux)
+	return nil
+}
+
+// Stop - signal for the service to stop serving
+func (server *Server) Stop() {
+	atomic.StoreInt32(&server.service.started, 0)
+}
+
+// remoteSub - bookkeeping entry pairing a remote subscriber with the local
+// handler it was registered with, so it can be torn down again
+type remoteSub struct {
+	arg     *SubscribeArg
+	handler func(args ...interface{})
+}
+
+// pushStatus - outcome of the last attempted push to a remote subscriber
+type pushStatus struct {
+	ok bool
+	at time.Time
+}
+
+// ServerService - service object registering and fanning out to remote subscribers
+type ServerService struct {
+	server   *Server
+	subs     map[string][]*remoteSub
+	lock     sync.Mutex
+	started  int32 // accessed atomically; 0 = stopped, 1 = started
+	opts     *ServerOptions
+	lastPush map[string]pushStatus
+}
+
+func (service *ServerService) isStarted() bool {
+	return atomic.LoadInt32(&service.started) == 1
+}
+
+func (service *ServerService) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthStatus(w, service.isStarted() && busResponsive(service.server.eventBus), "not healthy")
+}
+
+// readyzHandler additionally fails if the last push attempt to any currently
+// registered remote subscriber did not succeed
+func (service *ServerService) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !service.isStarted() || !busResponsive(service.server.eventBus) {
+		writeHealthStatus(w, false, "not healthy")
+		return
+	}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	registered := make(map[string]bool)
+	for _, subs := range service.subs {
+		for _, sub := range subs {
+			registered[sub.arg.ClientAddr+"|"+sub.arg.ClientPath] = true
+		}
+	}
+	for key, status := range service.lastPush {
+		if registered[key] && !status.ok {
+			writeHealthStatus(w, false, fmt.Sprintf("subscriber %s unreachable", key))
+			return
+		}
+	}
+	writeHealthStatus(w, true, "")
+}
+
+func (service *ServerService) recordPushResult(arg *SubscribeArg, ok bool) {
+	key := arg.ClientAddr + "|" + arg.ClientPath
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	service.lastPush[key] = pushStatus{ok: ok, at: time.Now()}
+}
+
+// Register - exported service registering a remote subscriber for a topic
+func (service *ServerService) Register(arg *SubscribeArg, reply *bool) error {
+	sub := &remoteSub{arg: arg}
+	sub.handler = func(args ...interface{}) {
+		// push retries with backoff, so it must not run on the Publish
+		// caller's goroutine or a single dead subscriber stalls every topic
+		go service.push(sub.arg, args)
+	}
+
+	var err error
+	if arg.Type == SubscribeTypeOnce {
+		err = service.server.eventBus.SubscribeOnce(arg.Topic, sub.handler)
+	} else {
+		err = service.server.eventBus.Subscribe(arg.Topic, sub.handler)
+	}
+	if err != nil {
+		return fmt.Errorf("Register error: %v", err)
+	}
+
+	service.lock.Lock()
+	service.subs[arg.Topic] = append(service.subs[arg.Topic], sub)
+	service.lock.Unlock()
+
+	*reply = true
+	return nil
+}
+
+// Unregister - exported service removing a single remote subscription for a topic
+func (service *ServerService) Unregister(arg *SubscribeArg, reply *bool) error {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	subs, ok := service.subs[arg.Topic]
+	if !ok {
+		*reply = false
+		return nil
+	}
+	remaining := subs[:0]
+	for _, sub := range subs {
+		if sub.arg.ClientAddr == arg.ClientAddr && sub.arg.ClientPath == arg.ClientPath {
+			service.server.eventBus.Unsubscribe(arg.Topic, sub.handler)
+			continue
+		}
+		remaining = append(remaining, sub)
+	}
+	service.subs[arg.Topic] = remaining
+	*reply = true
+	return nil
+}
+
+// UnregisterAll - exported service removing every subscription held by a remote client
+func (service *ServerService) UnregisterAll(arg *SubscribeArg, reply *bool) error {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	for topic, subs := range service.subs {
+		remaining := subs[:0]
+		for _, sub := range subs {
+			if sub.arg.ClientAddr == arg.ClientAddr && sub.arg.ClientPath == arg.ClientPath {
+				service.server.eventBus.Unsubscribe(topic, sub.handler)
+				continue
+			}
+			remaining = append(remaining, sub)
+		}
+		service.subs[topic] = remaining
+	}
+	*reply = true
+	return nil
+}
+
+// push - forwards a locally published event to the remote client that registered for it,
+// retrying with exponential backoff before evicting a subscriber that stays unreachable
+func (service *ServerService) push(arg *SubscribeArg, args []interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Client not found -", r)
+		}
+	}()
+
+	start := time.Now()
+	backoff := service.opts.PushInitialBackoff
+	var lastErr error
+	for attempt := 0; attempt <= service.opts.PushMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > service.opts.PushMaxBackoff {
+				backoff = service.opts.PushMaxBackoff
+			}
+		}
+		if lastErr = service.tryPush(arg, args); lastErr == nil {
+			pushEventLatency.WithLabelValues(arg.Topic).Observe(time.Since(start).Seconds())
+			service.recordPushResult(arg, true)
+			if arg.Type == SubscribeTypeOnce {
+				// the Bus already dropped the handler after this one delivery;
+				// drop our own bookkeeping entry so it doesn't linger forever
+				service.forgetSub(arg)
+			}
+			return
+		}
+	}
+	fmt.Println("PushEvent error, dropping subscriber:", lastErr)
+	service.recordPushResult(arg, false)
+	service.evict(arg)
+}
+
+func (service *ServerService) tryPush(arg *SubscribeArg, args []interface{}) error {
+	codec, ok := CodecByName(arg.Codec)
+	if !ok {
+		codec = GobCodec{}
+	}
+	encoded := make([][]byte, len(args))
+	for i, a := range args {
+		b, err := codec.Marshal(a)
+		if err != nil {
+			return fmt.Errorf("encoding arg %d: %v", i, err)
+		}
+		encoded[i] = b
+	}
+
+	rpcClient, err := rpc.DialHTTPPath("tcp", arg.ClientAddr, arg.ClientPath)
+	if err != nil {
+		return fmt.Errorf("dialing: %v", err)
+	}
+	defer rpcClient.Close()
+
+	clientArg := &ClientArg{Codec: arg.Codec, Topic: arg.Topic, Args: encoded}
+	reply := new(bool)
+	return rpcClient.Call(arg.ServiceMethod, clientArg, reply)
+}
+
+// forgetSub - drops a remoteSub's bookkeeping entry without touching the Bus,
+// for a SubscribeTypeOnce subscription that already delivered and removed
+// itself from the Bus on its own
+func (service *ServerService) forgetSub(arg *SubscribeArg) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	subs, ok := service.subs[arg.Topic]
+	if !ok {
+		return
+	}
+	remaining := subs[:0]
+	for _, sub := range subs {
+		if sub.arg == arg {
+			continue
+		}
+		remaining = append(remaining, sub)
+	}
+	service.subs[arg.Topic] = remaining
+}
+
+// evict - removes a subscriber that exhausted its push retries and announces
+// the eviction on the server's own Bus
+func (service *ServerService) evict(arg *SubscribeArg) {
+	service.lock.Lock()
+	subs, ok := service.subs[arg.Topic]
+	if ok {
+		remaining := subs[:0]
+		for _, sub := range subs {
+			if sub.arg == arg {
+				service.server.eventBus.Unsubscribe(arg.Topic, sub.handler)
+				continue
+			}
+			remaining = append(remaining, sub)
+		}
+		service.subs[arg.Topic] = remaining
+	}
+	service.lock.Unlock()
+
+	droppedSubscribersTotal.WithLabelValues(arg.Topic).Inc()
+	service.server.eventBus.Publish(DroppedSubscriberTopic, arg)
+}
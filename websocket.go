@@ -0,0 +1,277 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsEnvelope - JSON envelope exchanged between a WebSocketClient and a WebSocketServer
+type wsEnvelope struct {
+	Op    string            `json:"op"`
+	Topic string            `json:"topic,omitempty"`
+	Args  []json.RawMessage `json:"args,omitempty"`
+}
+
+const (
+	wsOpSubscribe      = "subscribe"
+	wsOpUnsubscribe    = "unsubscribe"
+	wsOpUnsubscribeAll = "unsubscribe_all"
+	wsOpEvent          = "event"
+)
+
+var wsUpgrader = websocket.Upgrader{}
+
+// WebSocketServer - object exposing a local Bus to browser-friendly WebSocket
+// subscribers, alongside the net/rpc Server
+type WebSocketServer struct {
+	eventBus *Bus
+	address  string
+	path     string
+	started  bool
+}
+
+// NewWebSocketServer - create a WebSocket server with the address and HTTP path it will listen on
+func NewWebSocketServer(address, path string, eventBus *Bus) *WebSocketServer {
+	return &WebSocketServer{eventBus: eventBus, address: address, path: path}
+}
+
+// Start - starts the WebSocket server to accept subscriber connections
+func (server *WebSocketServer) Start() error {
+	if server.started {
+		return errors.New("WebSocket server already started")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(server.path, server.handle)
+	l, err := net.Listen("tcp", server.address)
+	if err != nil {
+		return fmt.Errorf("listen error: %v", err)
+	}
+	server.started = true
+	go http.Serve(l, mux)
+	return nil
+}
+
+// Stop - signal for the server to stop serving
+func (server *WebSocketServer) Stop() {
+	if server.started {
+		server.started = false
+	}
+}
+
+func (server *WebSocketServer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		fmt.Println("upgrade error:", err)
+		return
+	}
+	client := &wsConn{conn: conn, subs: make(map[string]func(args ...interface{}))}
+	go server.serve(client)
+}
+
+// serve reads envelopes off a single connection until it closes, tearing down
+// every subscription it still holds so closing the socket never leaks a handler
+func (server *WebSocketServer) serve(client *wsConn) {
+	defer client.close(server.eventBus)
+	for {
+		var env wsEnvelope
+		if err := client.conn.ReadJSON(&env); err != nil {
+			return
+		}
+		switch env.Op {
+		case wsOpSubscribe:
+			server.subscribe(client, env.Topic)
+		case wsOpUnsubscribe:
+			client.unsubscribe(server.eventBus, env.Topic)
+		case wsOpUnsubscribeAll:
+			client.unsubscribeAll(server.eventBus)
+		}
+	}
+}
+
+func (server *WebSocketServer) subscribe(client *wsConn, topic string) {
+	handler := func(args ...interface{}) {
+		client.send(topic, args)
+	}
+	if err := server.eventBus.Subscribe(topic, handler); err != nil {
+		fmt.Println("subscribe error:", err)
+		return
+	}
+	client.trackSubscription(topic, handler)
+}
+
+// wsConn - tracks the topics a single connection is subscribed to, keyed by
+// the exact handler it was subscribed with, so it can unsubscribe precisely
+type wsConn struct {
+	conn *websocket.Conn
+	lock sync.Mutex
+	subs map[string]func(args ...interface{})
+}
+
+func (c *wsConn) send(topic string, args []interface{}) {
+	raw := make([]json.RawMessage, len(args))
+	for i, arg := range args {
+		b, err := json.Marshal(arg)
+		if err != nil {
+			fmt.Println("encoding arg:", err)
+			return
+		}
+		raw[i] = b
+	}
+	env := wsEnvelope{Op: wsOpEvent, Topic: topic, Args: raw}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.conn.WriteJSON(env); err != nil {
+		fmt.Println("write error:", err)
+	}
+}
+
+func (c *wsConn) trackSubscription(topic string, handler func(args ...interface{})) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.subs[topic] = handler
+}
+
+func (c *wsConn) unsubscribe(bus *Bus, topic string) {
+	c.lock.Lock()
+	handler, ok := c.subs[topic]
+	if ok {
+		delete(c.subs, topic)
+	}
+	c.lock.Unlock()
+	if ok {
+		bus.Unsubscribe(topic, handler)
+	}
+}
+
+func (c *wsConn) unsubscribeAll(bus *Bus) {
+	c.lock.Lock()
+	subs := c.subs
+	c.subs = make(map[string]func(args ...interface{}))
+	c.lock.Unlock()
+	for topic, handler := range subs {
+		bus.Unsubscribe(topic, handler)
+	}
+}
+
+func (c *wsConn) close(bus *Bus) {
+	c.unsubscribeAll(bus)
+	c.conn.Close()
+}
+
+// WebSocketClient - object capable of subscribing to a remote event bus over WebSocket
+type WebSocketClient struct {
+	eventBus *Bus
+	conn     *websocket.Conn
+	lock     sync.Mutex
+	handlers map[string]interface{}
+}
+
+// NewWebSocketClient - dials a WebSocketServer at the given address and path and
+// starts listening for events
+func NewWebSocketClient(serverAddr, serverPath string, eventBus *Bus) (*WebSocketClient, error) {
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+serverAddr+serverPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dialing: %v", err)
+	}
+	client := &WebSocketClient{eventBus: eventBus, conn: conn, handlers: make(map[string]interface{})}
+	go client.listen()
+	return client, nil
+}
+
+func (client *WebSocketClient) listen() {
+	for {
+		var env wsEnvelope
+		if err := client.conn.ReadJSON(&env); err != nil {
+			return
+		}
+		if env.Op != wsOpEvent {
+			continue
+		}
+		client.lock.Lock()
+		fn, ok := client.handlers[env.Topic]
+		client.lock.Unlock()
+		if !ok {
+			continue
+		}
+		args, err := decodeWSArgs(fn, env.Args)
+		if err != nil {
+			fmt.Println("decoding event:", err)
+			continue
+		}
+		client.eventBus.Publish(env.Topic, args...)
+	}
+}
+
+// decodeWSArgs unmarshals each JSON argument into the type fn actually expects,
+// so the Bus can invoke fn via reflection once Published locally
+func decodeWSArgs(fn interface{}, raw []json.RawMessage) ([]interface{}, error) {
+	t := reflect.TypeOf(fn)
+	args := make([]interface{}, len(raw))
+	for i, r := range raw {
+		paramType, err := argTypeAt(t, i)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decodeArg(JSONCodec{}, r, paramType)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// Subscribe subscribes fn to a topic in a remote event bus over WebSocket,
+// and to the client's own Bus so events relayed by listen() actually reach it
+func (client *WebSocketClient) Subscribe(topic string, fn interface{}) error {
+	if err := client.eventBus.Subscribe(topic, fn); err != nil {
+		return err
+	}
+	client.lock.Lock()
+	client.handlers[topic] = fn
+	client.lock.Unlock()
+	if err := client.conn.WriteJSON(wsEnvelope{Op: wsOpSubscribe, Topic: topic}); err != nil {
+		client.eventBus.Unsubscribe(topic, fn)
+		return err
+	}
+	return nil
+}
+
+// Unsubscribe tears down a single remote subscription, and removes the
+// matching handler from the client's own Bus
+func (client *WebSocketClient) Unsubscribe(topic string) error {
+	client.lock.Lock()
+	fn, ok := client.handlers[topic]
+	delete(client.handlers, topic)
+	client.lock.Unlock()
+	if ok {
+		client.eventBus.Unsubscribe(topic, fn)
+	}
+	return client.conn.WriteJSON(wsEnvelope{Op: wsOpUnsubscribe, Topic: topic})
+}
+
+// UnsubscribeAll tears down every remote subscription held with the server,
+// and removes all of the client's matching handlers from its own Bus
+func (client *WebSocketClient) UnsubscribeAll() error {
+	client.lock.Lock()
+	handlers := client.handlers
+	client.handlers = make(map[string]interface{})
+	client.lock.Unlock()
+	for topic, fn := range handlers {
+		client.eventBus.Unsubscribe(topic, fn)
+	}
+	return client.conn.WriteJSON(wsEnvelope{Op: wsOpUnsubscribeAll})
+}
+
+// Close closes the underlying WebSocket connection
+func (client *WebSocketClient) Close() error {
+	return client.conn.Close()
+}
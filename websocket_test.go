@@ -0,0 +1,53 @@
+package eventbus
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWebSocketClientSubscribeReceivesEvents(t *testing.T) {
+	addr := freeAddr(t)
+	serverBus := NewBus()
+	server := NewWebSocketServer(addr, "/ws", serverBus)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer server.Stop()
+
+	clientBus := NewBus()
+	client, err := NewWebSocketClient(addr, "/ws", clientBus)
+	if err != nil {
+		t.Fatalf("NewWebSocketClient: %v", err)
+	}
+	defer client.Close()
+
+	received := make(chan string, 1)
+	if err := client.Subscribe("topic", func(msg string) { received <- msg }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	waitUntil(t, func() bool { return serverBus.HasCallback("topic") })
+	serverBus.Publish("topic", "hello")
+
+	select {
+	case msg := <-received:
+		if msg != "hello" {
+			t.Errorf("got %q, want %q", msg, "hello")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was never invoked")
+	}
+}
+
+// freeAddr asks the OS for a free TCP port to listen a WebSocketServer on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening for a free port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}